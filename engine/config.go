@@ -0,0 +1,32 @@
+package engine
+
+import "github.com/jpillora/cloud-torrent/storage"
+
+//Config is the engine configuration, set by the user and applied via Configure
+type Config struct {
+	DownloadDirectory string
+	EnableUpload      bool
+	EnableSeeding     bool
+	EnableEncryption  bool
+	IncomingPort      int
+	//StorageBackend selects how piece data is persisted to disk: "file"
+	//(default), "mmap" or "piece". Leave empty for the default.
+	StorageBackend storage.Kind
+	//WebSeeds is a global list of HTTP/HTTPS mirror URLs (BEP-19) tried
+	//for every torrent, in addition to any url-list already present in
+	//its metainfo or passed per-torrent to NewByFile/NewByMagnet/NewByURL.
+	WebSeeds []string
+	//DisableMetainfoCache skips writing resume state to disk, for
+	//ephemeral use cases that never need to survive a restart.
+	DisableMetainfoCache bool
+	//DisableTrackers stops the client from announcing to or scraping any
+	//tracker, for trackerless/DHT-only swarms.
+	DisableTrackers bool
+	//DisableDHT turns off the DHT node, e.g. for private trackers that
+	//forbid it.
+	DisableDHT bool
+	//DisablePEX turns off peer exchange.
+	DisablePEX bool
+	//NoDefaultBlocklist skips loading the client's default IP blocklist.
+	NoDefaultBlocklist bool
+}
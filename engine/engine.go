@@ -1,72 +1,69 @@
 package engine
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
-	"os"
+	"net/http"
 	"path/filepath"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/anacrolix/torrent"
-	"github.com/anacrolix/torrent/bencode"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/jpillora/cloud-torrent/storage"
 )
 
-type IHash torrent.InfoHash
-
-func info2hash(info *metainfo.Info) IHash {
-	b, _ := bencode.Marshal(info)
-	ihash := IHash{}
-	h := sha1.New()
-	h.Write(b)
-	result := h.Sum(nil)
-	copy(ihash[:], result)
-	return ihash
-}
+//storageDir is the directory name, under DataDir, that backends write into.
+const storageDir = "data"
 
 //the Engine Cloud Torrent engine, backed by anacrolix/torrent
 type Engine struct {
 	//public torrents
 	Torrents map[string]*Torrent
 	//internal
-	mut         sync.Mutex
-	cacheDir    string
-	configuring bool
-	client      *torrent.Client
-	ts          map[torrent.InfoHash]*Torrent
-	openned     map[IHash]*Torrent
-	lastConfig  Config
+	mut            sync.Mutex
+	cacheDir       string
+	configuring    bool
+	client         *torrent.Client
+	ts             map[IHash]*Torrent
+	openned        map[IHash]*Torrent
+	openedAt       map[IHash]time.Time
+	lastConfig     Config
+	storageBackend storage.Backend
+	prunerStarted  bool
+	//pendingWebSeeds holds the merged mirror list for a magnet-added tt
+	//whose metainfo hasn't resolved into a *Torrent yet, so Update can
+	//record it on the Torrent once it's promoted; see NewByMagnet.
+	pendingWebSeeds map[*torrent.Torrent][]string
 }
 
 func New(storage *storage.Storage) *Engine {
 	return &Engine{
-		Torrents: map[string]*Torrent{},
-		ts:       map[torrent.InfoHash]*Torrent{},
-		openned:  map[IHash]*Torrent{},
+		Torrents:        map[string]*Torrent{},
+		ts:              map[IHash]*Torrent{},
+		openned:         map[IHash]*Torrent{},
+		openedAt:        map[IHash]time.Time{},
+		pendingWebSeeds: map[*torrent.Torrent][]string{},
 	}
 }
 
 func (e *Engine) Configure(c *Config) error {
-	//ensure locks
+	//ensure only one Configure runs at a time
 	e.mut.Lock()
-	defer func() {
-		e.mut.Unlock()
-		e.Update()
-	}()
 	if e.configuring {
+		e.mut.Unlock()
 		return fmt.Errorf("Configuration in progress")
 	}
-	//configuring...
+	e.configuring = true
+	e.mut.Unlock()
 	defer func() {
+		e.mut.Lock()
 		e.configuring = false
+		e.mut.Unlock()
+		e.Update()
 	}()
-	e.configuring = true
 	if c.IncomingPort <= 0 || c.IncomingPort >= 65535 {
 		c.IncomingPort = 50007
 	}
@@ -90,46 +87,74 @@ func (e *Engine) Configure(c *Config) error {
 		}
 	}
 	tc := torrent.Config{
-		DataDir:           c.DownloadDirectory,
-		ConfigDir:         filepath.Join(c.DownloadDirectory, ".config"),
-		ListenAddr:        "0.0.0.0:" + strconv.Itoa(c.IncomingPort),
-		NoUpload:          !c.EnableUpload,
-		Seed:              c.EnableSeeding,
-		DisableEncryption: !c.EnableEncryption,
-		TorrentDataOpener: e.OpenTorrent,
+		DataDir:            c.DownloadDirectory,
+		ConfigDir:          filepath.Join(c.DownloadDirectory, ".config"),
+		ListenAddr:         "0.0.0.0:" + strconv.Itoa(c.IncomingPort),
+		NoUpload:           !c.EnableUpload,
+		Seed:               c.EnableSeeding,
+		DisableEncryption:  !c.EnableEncryption,
+		TorrentDataOpener:  e.OpenTorrent,
+		DisableTrackers:    c.DisableTrackers,
+		NoDHT:              c.DisableDHT,
+		DisablePEX:         c.DisablePEX,
+		NoDefaultBlocklist: c.NoDefaultBlocklist,
 	}
 	client, err := torrent.NewClient(&tc)
 	if err != nil {
 		return err
 	}
+	backend, err := storage.NewBackend(c.StorageBackend, filepath.Join(c.DownloadDirectory, storageDir))
+	if err != nil {
+		client.Close()
+		return err
+	}
+	//swap in the new client/backend as one atomic step, so OpenTorrent (which
+	//locks e.mut for its own map access) never observes a half-configured Engine.
+	e.mut.Lock()
+	if e.storageBackend != nil {
+		e.storageBackend.Close()
+	}
+	e.storageBackend = backend
 	e.lastConfig = *c
 	e.client = client
-	e.cacheDir = filepath.Join(tc.ConfigDir, "torrents")
-	if files, err := ioutil.ReadDir(e.cacheDir); err == nil {
-		for _, f := range files {
-			if filepath.Ext(f.Name()) != ".torrent" {
-				continue
-			}
-			file, err := os.Open(filepath.Join(e.cacheDir, f.Name()))
-			if err != nil {
-				return err
-			}
-			e.NewByFile(file)
-		}
+	e.cacheDir = tc.ConfigDir
+	startPruner := !e.prunerStarted
+	e.prunerStarted = true
+	e.mut.Unlock()
+	//loadState re-adds torrents via NewByFile, which calls into
+	//AddTorrent/OpenTorrent - OpenTorrent takes e.mut itself, so it must be
+	//called with the lock released or it deadlocks against itself.
+	e.loadState(tc.ConfigDir)
+	if startPruner {
+		e.startPruner()
 	}
 	return nil
 }
 
-//OpenTorrent implements the torrent.Openner interface
-//and Torrent implements the torrent.Data interface
+//OpenTorrent implements the torrent.Openner interface and Torrent
+//implements the torrent.Data interface. The anacrolix/torrent client calls
+//this from its own goroutine whenever a torrent's metadata resolves, which
+//races pruneOpened's goroutine over the same e.openned/e.openedAt maps -
+//take e.mut for the duration rather than leaving it unsynchronized.
 func (e *Engine) OpenTorrent(info *metainfo.Info) torrent.Data {
 	ihash := info2hash(info)
+	e.mut.Lock()
+	defer e.mut.Unlock()
 	//load by infohash (cant error - valid ih and upserting)
 	t, ok := e.openned[ihash]
 	if !ok {
 		t = &Torrent{}
 		e.openned[ihash] = t
-		t.init(info)
+		e.openedAt[ihash] = time.Now()
+		data, err := e.storageBackend.Open(info)
+		if err != nil {
+			//TorrentDataOpener has no error return; surface the failure by
+			//leaving the torrent un-openned so it never appears ready
+			delete(e.openned, ihash)
+			delete(e.openedAt, ihash)
+			return nil
+		}
+		t.init(info, data)
 	}
 	//provide the torrent as its own "openner"
 	return t
@@ -144,30 +169,56 @@ func (e *Engine) Update() {
 		return
 	}
 	for _, tt := range e.client.Torrents() {
-		ih := tt.InfoHash()
 		ihash := info2hash(tt.Info())
 		t, ok := e.openned[ihash]
 		if ok {
 			delete(e.openned, ihash)
-			t.InfoHash = ih.HexString()
-			e.ts[ih] = t
-			e.Torrents[t.InfoHash] = t
-		} else {
-			t, ok = e.ts[ih]
-			if !ok {
-				t = &Torrent{}
-				e.ts[ih] = t
-				e.Torrents[ih.HexString()] = t
-			}
+			delete(e.openedAt, ihash)
+		} else if t, ok = e.lookup(ihash); !ok {
+			t = &Torrent{}
+		}
+		t.InfoHash = ihash.HexString()
+		e.index(ihash, t)
+		e.Torrents[t.InfoHash] = t
+		if merged, ok := e.pendingWebSeeds[tt]; ok {
+			t.WebSeeds = merged
+			delete(e.pendingWebSeeds, tt)
 		}
 		t.Update(tt)
 	}
+	e.saveState()
+}
+
+//lookup finds a Torrent already indexed under either half of a hybrid hash.
+func (e *Engine) lookup(ihash IHash) (*Torrent, bool) {
+	if ihash.HasV1 {
+		if t, ok := e.ts[IHash{V1: ihash.V1, HasV1: true}]; ok {
+			return t, true
+		}
+	}
+	if ihash.HasV2 {
+		if t, ok := e.ts[IHash{V2: ihash.V2, HasV2: true}]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+//index cross-links t under whichever of the v1/v2 infohashes are present,
+//so Get resolves either to the same Torrent.
+func (e *Engine) index(ihash IHash, t *Torrent) {
+	if ihash.HasV1 {
+		e.ts[IHash{V1: ihash.V1, HasV1: true}] = t
+	}
+	if ihash.HasV2 {
+		e.ts[IHash{V2: ihash.V2, HasV2: true}] = t
+	}
 }
 
 func (e *Engine) Get(hex string) (*Torrent, bool) {
 	e.mut.Lock()
 	defer e.mut.Unlock()
-	ih, err := validateInfohash(hex)
+	ih, err := parseInfohash(hex)
 	if err != nil {
 		return nil, false
 	}
@@ -175,51 +226,234 @@ func (e *Engine) Get(hex string) (*Torrent, bool) {
 	return t, ok
 }
 
-func (e *Engine) NewByMagnet(magnetURI string) error {
-	_, err := e.client.AddMagnet(magnetURI)
+//AddTrackers adds trackers as a new tier on an already-added torrent, e.g.
+//to add backup trackers to a magnet after it's loaded. anacrolix/torrent
+//has no way to drop a tier once added, so this only ever grows the
+//tracker list - it does not remove whatever trackers the torrent already had.
+func (e *Engine) AddTrackers(infohash string, trackers []string) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
 	if err != nil {
 		return err
 	}
+	t, ok := e.ts[ih]
+	if !ok || t.tt == nil {
+		return fmt.Errorf("Missing")
+	}
+	t.tt.AddTrackers([][]string{trackers})
 	return nil
 }
 
-func (e *Engine) NewByFile(body io.Reader) error {
+//SelectFiles marks the given file indices of the torrent identified by
+//infohash as wanted. It holds e.mut for the duration, so it's safe to
+//call concurrently with Update - unlike calling (*Torrent).SelectFiles
+//directly on a *Torrent returned by Get, which can race a Files reassignment.
+func (e *Engine) SelectFiles(infohash string, indices []int) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
+	if !ok {
+		return fmt.Errorf("Missing")
+	}
+	return t.SelectFiles(indices)
+}
+
+//DeselectFiles is the locked, Engine-level counterpart to SelectFiles.
+func (e *Engine) DeselectFiles(infohash string, indices []int) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
+	if !ok {
+		return fmt.Errorf("Missing")
+	}
+	return t.DeselectFiles(indices)
+}
+
+//SetRegionPriority is the locked, Engine-level counterpart to
+//(*Torrent).SetRegionPriority.
+func (e *Engine) SetRegionPriority(infohash string, off, length int64, priority int) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
+	if !ok {
+		return fmt.Errorf("Missing")
+	}
+	return t.SetRegionPriority(off, length, priority)
+}
+
+//Pause deselects every file of the torrent identified by infohash,
+//remembering which files were wanted so Resume can restore them, and
+//persists the paused flag so it survives a restart via resume state.
+func (e *Engine) Pause(infohash string) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
+	if !ok {
+		return fmt.Errorf("Missing")
+	}
+	var wanted []int
+	for i, f := range t.Files {
+		if f.Wanted {
+			wanted = append(wanted, i)
+		}
+	}
+	t.pausedSelection = wanted
+	indices := make([]int, len(t.Files))
+	for i := range t.Files {
+		indices[i] = i
+	}
+	if err := t.DeselectFiles(indices); err != nil {
+		return err
+	}
+	t.Paused = true
+	return nil
+}
+
+//Resume re-selects whatever files Pause recorded as wanted.
+func (e *Engine) Resume(infohash string) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
+	if !ok {
+		return fmt.Errorf("Missing")
+	}
+	if err := t.SelectFiles(t.pausedSelection); err != nil {
+		return err
+	}
+	t.Paused = false
+	return nil
+}
+
+func (e *Engine) NewByMagnet(magnetURI string, webseeds ...string) error {
+	tt, err := e.client.AddMagnet(magnetURI)
+	if err != nil {
+		return err
+	}
+	merged := e.mergeWebSeeds(nil, webseeds)
+	//a magnet's info dict isn't known yet, so there's no Torrent to record
+	//merged on until Update promotes tt - stash it here so SetWebSeedsEnabled
+	//has something to re-apply later, same as NewByFile does synchronously.
+	if len(merged) > 0 {
+		e.mut.Lock()
+		e.pendingWebSeeds[tt] = merged
+		e.mut.Unlock()
+	}
+	tt.AddWebSeeds(merged)
+	return nil
+}
+
+func (e *Engine) NewByFile(body io.Reader, webseeds ...string) error {
 	info, err := metainfo.Load(body)
 	if err != nil {
 		return err
 	}
-	_, err = e.client.AddTorrent(info)
+	tt, err := e.client.AddTorrent(info)
 	if err != nil {
 		return err
 	}
+	merged := e.mergeWebSeeds(info.UrlList, webseeds)
+	//info is fully known up front, so OpenTorrent has already run by the
+	//time AddTorrent returns: record the merged list on the Torrent and
+	//honor WebSeedsEnabled (false means the caller disabled web seeds for
+	//this torrent before the swarm/storage ever saw it).
+	e.mut.Lock()
+	t, ok := e.openned[info2hash(info)]
+	e.mut.Unlock()
+	if ok {
+		t.WebSeeds = merged
+		if !t.WebSeedsEnabled {
+			return nil
+		}
+	}
+	tt.AddWebSeeds(merged)
 	return nil
 }
 
-func (e *Engine) Remove(rmt *Torrent) error {
+//SetWebSeedsEnabled toggles per-torrent web seed use. Disabling stops
+//future (re-)application of the mirror list; re-enabling immediately
+//re-applies whatever mirror list was last merged for this torrent.
+func (e *Engine) SetWebSeedsEnabled(infohash string, enabled bool) error {
 	e.mut.Lock()
 	defer e.mut.Unlock()
-	id := rmt.id
-	t, ok := e.ts[id]
+	ih, err := parseInfohash(infohash)
+	if err != nil {
+		return err
+	}
+	t, ok := e.ts[ih]
 	if !ok {
 		return fmt.Errorf("Missing")
 	}
-	for _, f := range t.Files {
-		f.Stop()
+	t.WebSeedsEnabled = enabled
+	if enabled && t.tt != nil && len(t.WebSeeds) > 0 {
+		t.tt.AddWebSeeds(t.WebSeeds)
 	}
-	t.tt.Drop()
-	delete(e.ts, id)
-	delete(e.Torrents, id.HexString())
 	return nil
 }
 
-func validateInfohash(str string) (torrent.InfoHash, error) {
-	var ih torrent.InfoHash
-	e, err := hex.Decode(ih[:], []byte(str))
+//NewByURL fetches a .torrent file from torrentURL and adds it, attaching
+//webseeds as additional BEP-19 mirrors alongside any url-list it carries
+//and the global Config.WebSeeds.
+func (e *Engine) NewByURL(torrentURL string, webseeds ...string) error {
+	resp, err := http.Get(torrentURL)
 	if err != nil {
-		return ih, fmt.Errorf("Invalid hex string")
+		return err
 	}
-	if e != 20 {
-		return ih, fmt.Errorf("Invalid length")
+	defer resp.Body.Close()
+	return e.NewByFile(resp.Body, webseeds...)
+}
+
+func (e *Engine) Remove(rmt *Torrent) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if rmt.tt == nil {
+		return fmt.Errorf("Missing")
+	}
+	//confirm rmt is still the tracked Torrent for its hash, so a second
+	//Remove of the same *Torrent (e.g. a racing double-click) finds it
+	//already gone instead of dropping an already-dropped tt a second time.
+	if t, ok := e.lookup(rmt.ihash); !ok || t != rmt {
+		return fmt.Errorf("Missing")
 	}
-	return ih, nil
+	for _, f := range rmt.Files {
+		f.Stop()
+	}
+	info := rmt.tt.Info()
+	rmt.tt.Drop()
+	if err := rmt.Close(); err != nil {
+		return err
+	}
+	if info != nil && e.storageBackend != nil {
+		if err := e.storageBackend.Remove(info); err != nil {
+			return err
+		}
+	}
+	if rmt.ihash.HasV1 {
+		delete(e.ts, IHash{V1: rmt.ihash.V1, HasV1: true})
+	}
+	if rmt.ihash.HasV2 {
+		delete(e.ts, IHash{V2: rmt.ihash.V2, HasV2: true})
+	}
+	delete(e.Torrents, rmt.InfoHash)
+	return nil
 }
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+//IHash is a discriminated union over the 20-byte SHA-1 v1 infohash and the
+//32-byte SHA-256 v2 infohash introduced by BEP-52: v1-only, v2-only, or
+//both set for a hybrid torrent. It's the map key the engine uses before a
+//torrent has a live *torrent.Torrent to key off of.
+type IHash struct {
+	V1    torrent.InfoHash
+	V2    [32]byte
+	HasV1 bool
+	HasV2 bool
+}
+
+//HexString renders whichever hash is present, preferring v1, matching the
+//40/64 hex-char forms Get accepts.
+func (h IHash) HexString() string {
+	if h.HasV1 {
+		return h.V1.HexString()
+	}
+	return hex.EncodeToString(h.V2[:])
+}
+
+//info2hash computes the v1 infohash of info when it carries v1 fields
+//(legacy "pieces"/"length"/"files"), and its v2 infohash when it carries
+//a v2 "file tree" or sets "meta version" = 2 - a BEP-52 hybrid info dict
+//has both, so it's stored
+//under both and a magnet carrying only one still finds the same Torrent.
+//MetaVersion alone can't tell v1 from hybrid: hybrid torrents also set
+//"meta version" = 2 while keeping the legacy v1 fields for compatibility.
+//Per BEP-52, each half is hashed over its own view of the dict - the v1
+//hash excludes "file tree"/"meta version" and the v2 hash excludes
+//"pieces"/"length"/"files" - so a hybrid torrent's v1 hash matches what a
+//v1-only swarm/tracker computes for the same torrent, and likewise for v2.
+func info2hash(info *metainfo.Info) IHash {
+	ihash := IHash{}
+	if len(info.Pieces) > 0 || info.Length > 0 || len(info.Files) > 0 {
+		v1 := *info
+		v1.FileTree = nil
+		v1.MetaVersion = 0
+		b, _ := bencode.Marshal(&v1)
+		h := sha1.Sum(b)
+		copy(ihash.V1[:], h[:])
+		ihash.HasV1 = true
+	}
+	if info.MetaVersion == 2 || len(info.FileTree) > 0 {
+		v2 := *info
+		v2.Pieces = nil
+		v2.Length = 0
+		v2.Files = nil
+		b, _ := bencode.Marshal(&v2)
+		ihash.V2 = sha256.Sum256(b)
+		ihash.HasV2 = true
+	}
+	return ihash
+}
+
+//parseInfohash accepts a 40-hex-char v1 or 64-hex-char v2 infohash string,
+//as surfaced by Get. For a magnet of the form magnet:?xt=urn:btmh:1220...
+//only the 64 hex chars after the "1220" multihash prefix are accepted; the
+//prefixed 68-char string is rejected as an invalid length.
+func parseInfohash(str string) (IHash, error) {
+	var ih IHash
+	switch len(str) {
+	case 40:
+		n, err := hex.Decode(ih.V1[:], []byte(str))
+		if err != nil || n != 20 {
+			return ih, fmt.Errorf("invalid v1 infohash")
+		}
+		ih.HasV1 = true
+	case 64:
+		n, err := hex.Decode(ih.V2[:], []byte(str))
+		if err != nil || n != 32 {
+			return ih, fmt.Errorf("invalid v2 infohash")
+		}
+		ih.HasV2 = true
+	default:
+		return ih, fmt.Errorf("invalid infohash length")
+	}
+	return ih, nil
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestInfo2HashV1Only(t *testing.T) {
+	h := info2hash(&metainfo.Info{Name: "v1", Pieces: []byte{1, 2, 3}, Length: 100})
+	if !h.HasV1 || h.HasV2 {
+		t.Fatalf("got HasV1=%v HasV2=%v, want v1-only", h.HasV1, h.HasV2)
+	}
+}
+
+func TestInfo2HashV2Only(t *testing.T) {
+	h := info2hash(&metainfo.Info{Name: "v2", MetaVersion: 2})
+	if h.HasV1 || !h.HasV2 {
+		t.Fatalf("got HasV1=%v HasV2=%v, want v2-only", h.HasV1, h.HasV2)
+	}
+}
+
+//TestInfo2HashHybridMatchesV1Only pins the BEP-52 requirement that a hybrid
+//torrent's v1 infohash is identical to what's computed for the same legacy
+//fields without the v2-only additions, so a v1-only swarm/tracker computes
+//the same hash for it.
+func TestInfo2HashHybridMatchesV1Only(t *testing.T) {
+	legacy := metainfo.Info{Name: "x", Pieces: []byte{1, 2, 3}, Length: 100}
+	v1Only := legacy
+	hybrid := legacy
+	hybrid.MetaVersion = 2
+
+	hv1 := info2hash(&v1Only)
+	hh := info2hash(&hybrid)
+	if !hh.HasV1 || !hh.HasV2 {
+		t.Fatalf("got HasV1=%v HasV2=%v, want both set for a hybrid info", hh.HasV1, hh.HasV2)
+	}
+	if hv1.V1 != hh.V1 {
+		t.Fatalf("hybrid's v1 hash %x doesn't match the v1-only hash %x of the same legacy fields", hh.V1, hv1.V1)
+	}
+}
+
+func TestParseInfohash(t *testing.T) {
+	v1 := strings.Repeat("ab", 20)
+	v2 := strings.Repeat("cd", 32)
+
+	if ih, err := parseInfohash(v1); err != nil || !ih.HasV1 || ih.HasV2 {
+		t.Fatalf("parseInfohash(%q) = %+v, %v; want a valid v1-only hash", v1, ih, err)
+	}
+	if ih, err := parseInfohash(v2); err != nil || ih.HasV1 || !ih.HasV2 {
+		t.Fatalf("parseInfohash(%q) = %+v, %v; want a valid v2-only hash", v2, ih, err)
+	}
+	for _, bad := range []string{"", "not-hex-and-wrong-length", strings.Repeat("zz", 20)} {
+		if _, err := parseInfohash(bad); err == nil {
+			t.Errorf("parseInfohash(%q) succeeded, want an error", bad)
+		}
+	}
+}
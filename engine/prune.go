@@ -0,0 +1,31 @@
+package engine
+
+import "time"
+
+//openedPruneAfter is how long an OpenTorrent call may sit in e.openned
+//without being claimed by Update before it's considered abandoned (the
+//client decided not to actually add that torrent after all).
+const openedPruneAfter = 10 * time.Minute
+
+//startPruner launches the background pruner, mirroring the anacrolix
+//client's own pruneTimer: OpenTorrent entries that never make it into
+//e.client.Torrents() would otherwise leak forever.
+func (e *Engine) startPruner() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			e.pruneOpened()
+		}
+	}()
+}
+
+func (e *Engine) pruneOpened() {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	now := time.Now()
+	for ih, at := range e.openedAt {
+		if now.Sub(at) > openedPruneAfter {
+			delete(e.openned, ih)
+			delete(e.openedAt, ih)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+//stateFile is where resume state is persisted, relative to the torrent
+//client's ConfigDir.
+const stateFile = "state.db"
+
+//resumeEntry is the on-disk shape of one active torrent: enough to add it
+//straight back via NewByFile and restore the selection/paused state the
+//user left it in, without re-fetching its metainfo.
+type resumeEntry struct {
+	InfoHash      string
+	Metainfo      []byte
+	Downloaded    int64
+	Paused        bool
+	SelectedFiles []int
+}
+
+//saveState serializes the active torrent set so Configure can restore it
+//on the next startup. Callers must already hold e.mut. A no-op when
+//DisableMetainfoCache is set or the client isn't up yet.
+func (e *Engine) saveState() {
+	if e.lastConfig.DisableMetainfoCache || e.client == nil {
+		return
+	}
+	entries := make([]resumeEntry, 0, len(e.Torrents))
+	for _, t := range e.Torrents {
+		if t.tt == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := t.tt.Metainfo().Write(&buf); err != nil {
+			continue
+		}
+		entries = append(entries, resumeEntry{
+			InfoHash:      t.InfoHash,
+			Metainfo:      buf.Bytes(),
+			Downloaded:    t.Downloaded,
+			Paused:        t.Paused,
+			SelectedFiles: selectedFiles(t),
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	ioutil.WriteFile(filepath.Join(e.cacheDir, stateFile), b, 0644)
+}
+
+//selectedFiles reports which file indices are wanted, so loadState can
+//restore the same selection. A paused torrent has every file deselected
+//for display, so the set worth persisting is the one Resume would restore.
+func selectedFiles(t *Torrent) []int {
+	if t.Paused {
+		return t.pausedSelection
+	}
+	var indices []int
+	for i, f := range t.Files {
+		if f.Wanted {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+//loadState restores torrents saved by a prior saveState, called once from
+//Configure right after the client comes up. Each entry's info dict is known
+//synchronously from its cached metainfo, so OpenTorrent has already run a
+//Torrent into e.openned by the time NewByFile returns; the selection and
+//paused state are stashed there and re-applied by the Torrent itself once
+//Update populates its Files (see (*Torrent).applyPendingResume).
+func (e *Engine) loadState(configDir string) {
+	b, err := ioutil.ReadFile(filepath.Join(configDir, stateFile))
+	if err != nil {
+		return
+	}
+	var entries []resumeEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := metainfo.Load(bytes.NewReader(entry.Metainfo))
+		if err != nil {
+			continue
+		}
+		ihash := info2hash(info)
+		if err := e.NewByFile(bytes.NewReader(entry.Metainfo)); err != nil {
+			continue
+		}
+		t, ok := e.openned[ihash]
+		if !ok {
+			continue
+		}
+		t.Downloaded = entry.Downloaded
+		t.Paused = entry.Paused
+		t.pausedSelection = entry.SelectedFiles
+	}
+}
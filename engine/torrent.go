@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+//Torrent is the public, JSON-serialisable view of a torrent.Torrent that
+//cloud-torrent hands to the web UI, plus the bookkeeping needed to
+//implement torrent.Data by delegating to a pluggable storage.Backend.
+type Torrent struct {
+	InfoHash string
+	Name     string
+	Magnet   string
+	Size     int64
+	Files    []*File
+
+	//WebSeedsEnabled toggles whether this torrent's web seeds (BEP-19)
+	//are tried. Defaults to true; set false via Engine.SetWebSeedsEnabled
+	//to force swarm-only transfer. Checked by NewByFile before the initial
+	//AddWebSeeds call and re-applied if flipped back on.
+	WebSeedsEnabled bool
+	//WebSeeds is the last merged mirror list passed to AddWebSeeds, kept
+	//around so re-enabling WebSeedsEnabled can re-apply it.
+	WebSeeds []string
+	//WebSeedBytes is the running total of bytes pulled from web seeds.
+	WebSeedBytes int64
+	//Downloaded is the last known bytes-completed count. Update refreshes
+	//it from the live torrent every tick; loadState seeds it from disk so
+	//a just-restored torrent reports its prior progress immediately,
+	//rather than 0, before the first tick lands.
+	Downloaded int64
+	//Paused is true once Engine.Pause has deselected every file. Engine.Resume
+	//re-selects whatever was wanted beforehand, recorded in pausedSelection.
+	Paused bool
+
+	ihash           IHash
+	tt              *torrent.Torrent
+	data            torrent.Data
+	pausedSelection []int
+	resumeApplied   bool
+}
+
+//File is the public view of a single file inside a (possibly multi-file) torrent.
+type File struct {
+	Path string
+	Size int64
+	//Wanted mirrors the file's selected/deselected state so resume state
+	//can be saved and restored; true unless DeselectFiles was called on it.
+	Wanted bool
+
+	tf *torrent.File
+}
+
+//Stop cancels any in-flight priority boosts for this file, e.g. on Remove.
+func (f *File) Stop() {
+	if f.tf != nil {
+		f.tf.SetPriority(torrent.PiecePriorityNone)
+	}
+}
+
+//init seeds the public fields from the torrent's info dict and binds data
+//as the backing store that ReadAt/WriteAt/Close delegate to.
+func (t *Torrent) init(info *metainfo.Info, data torrent.Data) {
+	t.Name = info.Name
+	t.Size = info.TotalLength()
+	t.data = data
+	t.WebSeedsEnabled = true
+}
+
+//Update refreshes the public fields from the live anacrolix/torrent handle.
+func (t *Torrent) Update(tt *torrent.Torrent) {
+	t.tt = tt
+	t.Name = tt.Name()
+	t.Downloaded = tt.BytesCompleted()
+	if info := tt.Info(); info != nil {
+		t.ihash = info2hash(info)
+		t.Size = info.TotalLength()
+		files := tt.Files()
+		//carry Wanted forward by path so a Files rebuild doesn't erase
+		//selections made since the previous tick; new files default wanted.
+		prevWanted := make(map[string]bool, len(t.Files))
+		for _, f := range t.Files {
+			prevWanted[f.Path] = f.Wanted
+		}
+		t.Files = make([]*File, len(files))
+		for i, tf := range files {
+			wanted, ok := prevWanted[tf.Path()]
+			if !ok {
+				wanted = true
+			}
+			t.Files[i] = &File{Path: tf.Path(), Size: tf.Length(), Wanted: wanted, tf: tf}
+		}
+		t.applyPendingResume()
+	}
+	t.WebSeedBytes = tt.Stats().BytesReadWebSeed
+}
+
+//applyPendingResume re-applies the selection/paused state loadState stashed
+//on this Torrent before its Files existed, the first tick Files is populated.
+func (t *Torrent) applyPendingResume() {
+	if t.resumeApplied {
+		return
+	}
+	t.resumeApplied = true
+	if t.Paused {
+		indices := make([]int, len(t.Files))
+		for i := range t.Files {
+			indices[i] = i
+		}
+		t.DeselectFiles(indices)
+	} else if t.pausedSelection != nil {
+		selected := make(map[int]bool, len(t.pausedSelection))
+		for _, i := range t.pausedSelection {
+			selected[i] = true
+		}
+		var unwanted []int
+		for i := range t.Files {
+			if !selected[i] {
+				unwanted = append(unwanted, i)
+			}
+		}
+		t.DeselectFiles(unwanted)
+		t.SelectFiles(t.pausedSelection)
+	}
+}
+
+//ReadAt, WriteAt and Close implement torrent.Data by delegating to
+//whichever storage.Backend produced t.data in Engine.OpenTorrent.
+func (t *Torrent) ReadAt(p []byte, off int64) (int, error) {
+	return t.data.ReadAt(p, off)
+}
+
+func (t *Torrent) WriteAt(p []byte, off int64) (int, error) {
+	return t.data.WriteAt(p, off)
+}
+
+func (t *Torrent) Close() error {
+	if t.data == nil {
+		return nil
+	}
+	return t.data.Close()
+}
+
+//BytesCompleted reports how many bytes of this torrent have been
+//downloaded so far, or 0 if it isn't backed by a live torrent yet.
+func (t *Torrent) BytesCompleted() int64 {
+	if t.tt == nil {
+		return 0
+	}
+	return t.tt.BytesCompleted()
+}
+
+//Done reports whether every byte of the torrent has been downloaded.
+func (t *Torrent) Done() bool {
+	return t.tt != nil && t.Size > 0 && t.BytesCompleted() >= t.Size
+}
+
+//SelectFiles marks the given file indices (into t.Files) as wanted,
+//so the client downloads them. Files not selected via either method
+//default to whatever priority anacrolix/torrent assigns on add.
+//Callers must hold the owning Engine's mut: Update reassigns t.Files
+//concurrently on every tick, so use Engine.SelectFiles from outside
+//the engine package.
+func (t *Torrent) SelectFiles(indices []int) error {
+	for _, i := range indices {
+		if i < 0 || i >= len(t.Files) {
+			return fmt.Errorf("file index %d out of range", i)
+		}
+		t.Files[i].tf.SetPriority(torrent.PiecePriorityNormal)
+		t.Files[i].Wanted = true
+	}
+	return nil
+}
+
+//DeselectFiles marks the given file indices as unwanted, so their pieces
+//are skipped entirely. Callers must hold the owning Engine's mut; see
+//SelectFiles.
+func (t *Torrent) DeselectFiles(indices []int) error {
+	for _, i := range indices {
+		if i < 0 || i >= len(t.Files) {
+			return fmt.Errorf("file index %d out of range", i)
+		}
+		t.Files[i].tf.SetPriority(torrent.PiecePriorityNone)
+		t.Files[i].Wanted = false
+	}
+	return nil
+}
+
+//SetRegionPriority raises (or lowers) the priority of every piece that
+//overlaps the byte range off to off+length, so a media player seek can
+//hoist the bytes it needs next to the front of the download queue.
+//Callers must hold the owning Engine's mut; see SelectFiles.
+func (t *Torrent) SetRegionPriority(off, length int64, priority int) error {
+	if t.tt == nil {
+		return fmt.Errorf("torrent not ready")
+	}
+	info := t.tt.Info()
+	if info == nil {
+		return fmt.Errorf("torrent not ready")
+	}
+	if off < 0 || length <= 0 || off+length > info.TotalLength() {
+		return fmt.Errorf("region [%d, %d) out of range for a %d byte torrent", off, off+length, info.TotalLength())
+	}
+	pieceLen := int64(info.PieceLength)
+	first := off / pieceLen
+	last := (off + length - 1) / pieceLen
+	for i := first; i <= last; i++ {
+		t.tt.Piece(int(i)).SetPriority(torrent.PiecePriority(priority))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package engine
+
+//mergeWebSeeds combines the global Config.WebSeeds mirror list with any
+//url-list already embedded in a torrent's metainfo and whatever mirrors
+//the caller passed for this one torrent, de-duplicating as it goes.
+func (e *Engine) mergeWebSeeds(metainfoURLs, perTorrent []string) []string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, group := range [][]string{metainfoURLs, e.lastConfig.WebSeeds, perTorrent} {
+		for _, u := range group {
+			if u == "" || seen[u] {
+				continue
+			}
+			seen[u] = true
+			merged = append(merged, u)
+		}
+	}
+	return merged
+}
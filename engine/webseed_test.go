@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeWebSeeds(t *testing.T) {
+	e := &Engine{lastConfig: Config{WebSeeds: []string{"https://global.example/a", "https://dup.example/b"}}}
+
+	got := e.mergeWebSeeds(
+		[]string{"https://metainfo.example/c", "https://dup.example/b", ""},
+		[]string{"https://percall.example/d", "https://metainfo.example/c"},
+	)
+	want := []string{
+		"https://metainfo.example/c",
+		"https://dup.example/b",
+		"https://global.example/a",
+		"https://percall.example/d",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeWebSeeds() = %v, want %v", got, want)
+	}
+}
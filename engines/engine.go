@@ -0,0 +1,52 @@
+//Package engines defines the backend-agnostic surface that every
+//download engine (anacrolix/torrent-backed "native", and future
+//HTTP/NZB/WebRTC engines) implements, so main can register several of
+//them side by side behind the same web UI, storage layer and RSS pipeline.
+package engines
+
+import (
+	"encoding/json"
+	"io"
+)
+
+//ID identifies a registered engine, e.g. "native". It prefixes infohashes
+//in the aggregated Torrents map so two engines can never collide.
+type ID string
+
+//Engine is implemented by every torrent/download backend.
+type Engine interface {
+	//Name is this engine's ID, used as the aggregation-key prefix.
+	Name() ID
+	//SetConfig (re)configures the engine from its own JSON-shaped config.
+	SetConfig(c json.RawMessage) error
+	//Magnet adds an item by magnet URI or equivalent locator.
+	Magnet(uri string) error
+	//NewByFile adds an item described by body (e.g. a .torrent file).
+	NewByFile(body io.Reader) error
+	//Get looks up a previously added item by its per-engine ID.
+	Get(id string) (*Torrent, bool)
+	//Remove cancels and forgets a previously added item.
+	Remove(id string) error
+	//Torrents is pushed a fresh snapshot whenever this engine's view of
+	//its items changes.
+	Torrents() <-chan map[string]*Torrent
+}
+
+//PollingEngine is implemented by backends that cannot push updates and
+//must be scraped on a timer instead (e.g. an NZB indexer).
+type PollingEngine interface {
+	Engine
+	//Poll refreshes state and pushes a fresh snapshot onto Torrents.
+	Poll() error
+}
+
+//Torrent is the shape every engine normalises its items into, so the web
+//UI, storage layer and RSS pipeline can work with any backend uniformly.
+type Torrent struct {
+	EngineID   ID
+	ID         string
+	Name       string
+	Size       int64
+	Downloaded int64
+	Done       bool
+}
@@ -0,0 +1,119 @@
+//Package native adapts the original anacrolix/torrent-backed engine.Engine
+//onto the engines.Engine interface, so it can be registered alongside
+//future non-BitTorrent backends behind the same web UI.
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jpillora/cloud-torrent/engine"
+	"github.com/jpillora/cloud-torrent/engines"
+	"github.com/jpillora/cloud-torrent/storage"
+)
+
+//Native is the default engines.Engine, wrapping engine.Engine.
+type Native struct {
+	*engine.Engine
+	updates chan map[string]*engines.Torrent
+}
+
+//New wraps a fresh engine.Engine as a Native engines.Engine.
+func New(store *storage.Storage) *Native {
+	return &Native{
+		Engine:  engine.New(store),
+		updates: make(chan map[string]*engines.Torrent, 1),
+	}
+}
+
+func (n *Native) Name() engines.ID { return "native" }
+
+func (n *Native) SetConfig(raw json.RawMessage) error {
+	var c engine.Config
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return err
+	}
+	if err := n.Engine.Configure(&c); err != nil {
+		return err
+	}
+	n.push()
+	return nil
+}
+
+func (n *Native) Magnet(uri string) error {
+	err := n.Engine.NewByMagnet(uri)
+	n.push()
+	return err
+}
+
+func (n *Native) NewByFile(body io.Reader) error {
+	err := n.Engine.NewByFile(body)
+	n.push()
+	return err
+}
+
+//MagnetWithWebSeeds is Magnet plus per-torrent BEP-19 mirrors. Web seeds
+//are a BitTorrent-only concept, so they're exposed here on Native rather
+//than on the generic engines.Engine interface, which future non-BitTorrent
+//backends (HTTP/NZB/WebRTC) also implement and have no use for them.
+func (n *Native) MagnetWithWebSeeds(uri string, webseeds ...string) error {
+	err := n.Engine.NewByMagnet(uri, webseeds...)
+	n.push()
+	return err
+}
+
+//NewByFileWithWebSeeds is NewByFile plus per-torrent BEP-19 mirrors; see
+//MagnetWithWebSeeds for why this isn't on the generic interface.
+func (n *Native) NewByFileWithWebSeeds(body io.Reader, webseeds ...string) error {
+	err := n.Engine.NewByFile(body, webseeds...)
+	n.push()
+	return err
+}
+
+func (n *Native) Get(id string) (*engines.Torrent, bool) {
+	t, ok := n.Engine.Get(id)
+	if !ok {
+		return nil, false
+	}
+	return toEngineTorrent(t), true
+}
+
+func (n *Native) Remove(id string) error {
+	t, ok := n.Engine.Get(id)
+	if !ok {
+		return fmt.Errorf("unknown torrent %q", id)
+	}
+	err := n.Engine.Remove(t)
+	n.push()
+	return err
+}
+
+func (n *Native) Torrents() <-chan map[string]*engines.Torrent {
+	return n.updates
+}
+
+//push republishes the current native Torrents map as an engines.Torrent
+//snapshot, dropping it if a Registry isn't ready to receive yet.
+func (n *Native) push() {
+	n.Engine.Update()
+	out := make(map[string]*engines.Torrent, len(n.Engine.Torrents))
+	for id, t := range n.Engine.Torrents {
+		out[id] = toEngineTorrent(t)
+	}
+	select {
+	case n.updates <- out:
+	default:
+	}
+}
+
+func toEngineTorrent(t *engine.Torrent) *engines.Torrent {
+	return &engines.Torrent{
+		EngineID:   "native",
+		ID:         t.InfoHash,
+		Name:       t.Name,
+		Size:       t.Size,
+		Downloaded: t.BytesCompleted(),
+		Done:       t.Done(),
+	}
+}
@@ -0,0 +1,69 @@
+package engines
+
+import (
+	"strings"
+	"sync"
+)
+
+//Registry aggregates Torrents from every registered Engine into one map
+//keyed by "engineID:id", so the web UI sees one flat torrent list
+//regardless of how many backends are active.
+type Registry struct {
+	mut      sync.Mutex
+	engines  map[ID]Engine
+	Torrents map[string]*Torrent
+}
+
+//NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		engines:  map[ID]Engine{},
+		Torrents: map[string]*Torrent{},
+	}
+}
+
+//Register adds e to the registry and starts aggregating its updates.
+//A PollingEngine is also registered, but the host is responsible for
+//calling its Poll method on a timer - Registry only consumes whatever
+//lands on Torrents().
+func (r *Registry) Register(e Engine) {
+	r.mut.Lock()
+	r.engines[e.Name()] = e
+	r.mut.Unlock()
+	go r.watch(e)
+}
+
+func (r *Registry) watch(e Engine) {
+	for snapshot := range e.Torrents() {
+		r.merge(e.Name(), snapshot)
+	}
+}
+
+//merge replaces everything keyed under id with the latest snapshot - each
+//snapshot is that engine's full current torrent set, so any key under id
+//missing from it belongs to a torrent that engine no longer has (e.g.
+//removed) and must be dropped, not left behind.
+func (r *Registry) merge(id ID, snapshot map[string]*Torrent) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	prefix := string(id) + ":"
+	for key := range r.Torrents {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if _, ok := snapshot[strings.TrimPrefix(key, prefix)]; !ok {
+			delete(r.Torrents, key)
+		}
+	}
+	for _, t := range snapshot {
+		r.Torrents[prefix+t.ID] = t
+	}
+}
+
+//Engine looks up a registered backend by ID.
+func (r *Registry) Engine(id ID) (Engine, bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	e, ok := r.engines[id]
+	return e, ok
+}
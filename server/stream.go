@@ -0,0 +1,60 @@
+//Package server wires cloud-torrent's engine(s) onto the REST API the
+//web UI calls.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jpillora/cloud-torrent/engine"
+)
+
+//StreamHandler exposes per-torrent file selection and region priority, so
+//the browser can select just the file(s) it wants and hoist byte ranges
+//to the front of the download queue as a seek happens in a media player.
+type StreamHandler struct {
+	Engine *engine.Engine
+}
+
+type selectRequest struct {
+	Indices []int `json:"indices"`
+}
+
+type regionRequest struct {
+	Offset   int64 `json:"offset"`
+	Length   int64 `json:"length"`
+	Priority int   `json:"priority"`
+}
+
+//ServeHTTP handles POST /api/torrents/{infohash}/{select,deselect,region}.
+//infohash and action are passed as query parameters by the caller's router.
+//It goes through the Engine's locked SelectFiles/DeselectFiles/
+//SetRegionPriority rather than Get + a direct *Torrent call, so a request
+//racing an Update tick can't read a half-replaced Files slice.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	infohash := r.URL.Query().Get("infohash")
+	var err error
+	switch r.URL.Query().Get("action") {
+	case "select":
+		var req selectRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = h.Engine.SelectFiles(infohash, req.Indices)
+		}
+	case "deselect":
+		var req selectRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = h.Engine.DeselectFiles(infohash, req.Indices)
+		}
+	case "region":
+		var req regionRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = h.Engine.SetRegionPriority(infohash, req.Offset, req.Length, req.Priority)
+		}
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
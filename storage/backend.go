@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Backend opens torrent.Data for a torrent's info dict, so the engine can
+// swap how piece bytes are persisted without touching client wiring.
+// Implement this to back torrents with something other than the local
+// filesystem, e.g. an S3 or HTTP-backed store.
+type Backend interface {
+	Open(info *metainfo.Info) (torrent.Data, error)
+	//Remove deletes whatever on-disk state Open created for info, so a
+	//removed torrent doesn't leave orphaned files (or, for KindPiece,
+	//an orphaned piece directory) behind.
+	Remove(info *metainfo.Info) error
+	Close()
+}
+
+// Kind names a built-in Backend, selectable via Config.StorageBackend.
+type Kind string
+
+const (
+	// KindFile is the default: one backing file per torrent, multi-file
+	// torrents included, written to sequentially as pieces complete.
+	KindFile Kind = "file"
+	// KindMMap whole-file mmaps each torrent, which suits random-access
+	// reads and seeking media playback.
+	KindMMap Kind = "mmap"
+	// KindPiece stores each piece in its own file, so incomplete pieces
+	// are trivial to garbage-collect on Remove.
+	KindPiece Kind = "piece"
+)
+
+// NewBackend constructs one of the built-in backends rooted at dir.
+func NewBackend(kind Kind, dir string) (Backend, error) {
+	switch kind {
+	case "", KindFile:
+		return NewFileBackend(dir), nil
+	case KindMMap:
+		return NewMMapBackend(dir), nil
+	case KindPiece:
+		return NewPieceBackend(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", kind)
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// FileBackend is the original cloud-torrent storage: each torrent gets a
+// single backing file on disk (matching its total length), written to
+// sequentially as pieces complete.
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend returns a Backend that writes torrent data under dir.
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{dir: dir}
+}
+
+func (b *FileBackend) Open(info *metainfo.Info) (torrent.Data, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(b.dir, info.Name+".data"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(info.TotalLength()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileData{f: f}, nil
+}
+
+func (b *FileBackend) Remove(info *metainfo.Info) error {
+	err := os.Remove(filepath.Join(b.dir, info.Name+".data"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *FileBackend) Close() {}
+
+type fileData struct {
+	mut sync.Mutex
+	f   *os.File
+}
+
+func (d *fileData) ReadAt(p []byte, off int64) (int, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.f.ReadAt(p, off)
+}
+
+func (d *fileData) WriteAt(p []byte, off int64) (int, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.f.WriteAt(p, off)
+}
+
+func (d *fileData) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.f.Close()
+}
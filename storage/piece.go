@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// PieceBackend stores each piece in its own file under a per-torrent
+// directory, named by piece index. Unlike FileBackend, incomplete pieces
+// cost nothing to discard: Remove can just delete files that were never
+// written rather than punching holes in one large backing file.
+type PieceBackend struct {
+	dir string
+}
+
+// NewPieceBackend returns a Backend that writes one file per piece under dir.
+func NewPieceBackend(dir string) *PieceBackend {
+	return &PieceBackend{dir: dir}
+}
+
+func (b *PieceBackend) Open(info *metainfo.Info) (torrent.Data, error) {
+	root := filepath.Join(b.dir, info.Name+".pieces")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &pieceData{
+		dir:        root,
+		pieceLen:   int64(info.PieceLength),
+		totalLen:   info.TotalLength(),
+		openPieces: map[int]*os.File{},
+	}, nil
+}
+
+func (b *PieceBackend) Close() {}
+
+type pieceData struct {
+	mut        sync.Mutex
+	dir        string
+	pieceLen   int64
+	totalLen   int64
+	openPieces map[int]*os.File
+}
+
+func (d *pieceData) pieceFile(index int) (*os.File, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	if f, ok := d.openPieces[index]; ok {
+		return f, nil
+	}
+	length := d.pieceLen
+	if last := d.totalLen - int64(index)*d.pieceLen; last < length {
+		length = last
+	}
+	f, err := os.OpenFile(filepath.Join(d.dir, fmt.Sprintf("%08d.piece", index)), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(length); err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.openPieces[index] = f
+	return f, nil
+}
+
+// split breaks an off+len span into the (piece index, piece-relative
+// offset, length) triples it touches.
+func (d *pieceData) split(off int64, n int) [][3]int64 {
+	var spans [][3]int64
+	end := off + int64(n)
+	for cur := off; cur < end; {
+		index := cur / d.pieceLen
+		pieceOff := cur % d.pieceLen
+		want := d.pieceLen - pieceOff
+		if remaining := end - cur; remaining < want {
+			want = remaining
+		}
+		spans = append(spans, [3]int64{index, pieceOff, want})
+		cur += want
+	}
+	return spans
+}
+
+func (d *pieceData) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for _, span := range d.split(off, len(p)) {
+		index, pieceOff, n := int(span[0]), span[1], span[2]
+		f, err := d.pieceFile(index)
+		if err != nil {
+			return read, err
+		}
+		nn, err := f.ReadAt(p[read:read+int(n)], pieceOff)
+		read += nn
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func (d *pieceData) WriteAt(p []byte, off int64) (int, error) {
+	written := 0
+	for _, span := range d.split(off, len(p)) {
+		index, pieceOff, n := int(span[0]), span[1], span[2]
+		f, err := d.pieceFile(index)
+		if err != nil {
+			return written, err
+		}
+		nn, err := f.WriteAt(p[written:written+int(n)], pieceOff)
+		written += nn
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (d *pieceData) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	var firstErr error
+	for _, f := range d.openPieces {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Remove deletes the torrent's whole piece directory, including any
+// incomplete pieces that were never written - the garbage-collection
+// this backend exists for.
+func (b *PieceBackend) Remove(info *metainfo.Info) error {
+	return os.RemoveAll(filepath.Join(b.dir, info.Name+".pieces"))
+}
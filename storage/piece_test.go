@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPieceDataSplit(t *testing.T) {
+	d := &pieceData{pieceLen: 10}
+
+	cases := []struct {
+		name  string
+		off   int64
+		n     int
+		spans [][3]int64
+	}{
+		{"within one piece", 2, 5, [][3]int64{{0, 2, 5}}},
+		{"piece boundary", 8, 4, [][3]int64{{0, 8, 2}, {1, 0, 2}}},
+		{"spans three pieces", 5, 20, [][3]int64{{0, 5, 5}, {1, 0, 10}, {2, 0, 5}}},
+		{"starts on a piece boundary", 10, 10, [][3]int64{{1, 0, 10}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := d.split(c.off, c.n)
+			if !reflect.DeepEqual(got, c.spans) {
+				t.Errorf("split(%d, %d) = %v, want %v", c.off, c.n, got, c.spans)
+			}
+		})
+	}
+}
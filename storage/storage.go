@@ -0,0 +1,30 @@
+// Package storage holds the on-disk concerns of cloud-torrent: serving
+// completed files to the web UI, and persisting piece data for the engine.
+package storage
+
+import (
+	"net/http"
+	"os"
+)
+
+// Storage serves the download directory over HTTP for the web UI
+// (file listing, partial-content downloads of in-progress torrents).
+type Storage struct {
+	Dir string
+}
+
+// New creates a Storage rooted at dir.
+func New(dir string) *Storage {
+	return &Storage{Dir: dir}
+}
+
+// FileServer returns an http.Handler serving files under Dir.
+func (s *Storage) FileServer() http.Handler {
+	return http.FileServer(http.Dir(s.Dir))
+}
+
+// Stat is a convenience wrapper used by callers that only need to check
+// a path exists under Dir before serving it.
+func (s *Storage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(s.Dir + string(os.PathSeparator) + name)
+}